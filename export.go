@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// RunRecord is the JSON-lines representation of a single Run or RetryRun
+// call, written alongside the CSV so that results can be ingested by
+// Grafana/Loki. For a RetryRun, Duration is accumulated over every attempt
+// while Command, ExitCode and StderrExcerpt come from the last attempt.
+type RunRecord struct {
+	Timestamp     time.Time     `json:"timestamp"`
+	Iteration     int           `json:"iteration"`
+	TestCase      string        `json:"test_case"`
+	Phase         string        `json:"phase"`
+	Command       []string      `json:"command"`
+	Duration      time.Duration `json:"duration_ns"`
+	ExitCode      int           `json:"exit_code"`
+	StderrExcerpt string        `json:"stderr_excerpt,omitempty"`
+	// NodeJoinTimes is set only for a "node_ready_check" phase (see
+	// Phase.Type): each ready node's join latency, keyed by node name.
+	NodeJoinTimes map[string]time.Duration `json:"node_join_times,omitempty"`
+}
+
+// runRecorder writes RunRecords as newline-delimited JSON, serializing
+// concurrent writers from --parallel workers.
+type runRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newRunRecorder(w io.Writer) *runRecorder {
+	return &runRecorder{enc: json.NewEncoder(w)}
+}
+
+func (r *runRecorder) record(rec RunRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(rec); err != nil {
+		klog.Errorf("unable to write run record: %v", err)
+	}
+}
+
+// jsonlRecorder is set up once in main; recordRun is a no-op until then.
+var jsonlRecorder *runRecorder
+
+// stderrExcerptLen trims stderr down to a size sane for a JSON-lines sidecar file.
+const stderrExcerptLen = 2048
+
+// recordRun appends a RunResult to the configured JSON-lines sidecar file, if any.
+// It is skipped for the dry-run iteration (iteration 0), matching the CSV output.
+// nodeJoinTimes is only non-nil for a "node_ready_check" phase.
+func recordRun(iteration int, testCase string, phase string, rr *RunResult, nodeJoinTimes map[string]time.Duration) {
+	if jsonlRecorder == nil || iteration == 0 || rr == nil {
+		return
+	}
+
+	stderr := rr.Stderr.String()
+	if len(stderr) > stderrExcerptLen {
+		stderr = stderr[:stderrExcerptLen]
+	}
+
+	jsonlRecorder.record(RunRecord{
+		Timestamp:     time.Now(),
+		Iteration:     iteration,
+		TestCase:      testCase,
+		Phase:         phase,
+		Command:       rr.Args,
+		Duration:      rr.Duration,
+		ExitCode:      rr.ExitCode,
+		StderrExcerpt: stderr,
+		NodeJoinTimes: nodeJoinTimes,
+	})
+}
+
+// prometheusMetrics renders an ExperimentResult as Prometheus/OpenMetrics text
+// format, one gauge per phase plus a total, labeled by distro/version/platform/
+// iteration/phase so results can be compared across runs.
+func prometheusMetrics(e ExperimentResult, platform string, iteration int) string {
+	var b strings.Builder
+
+	writeGauge := func(phase string, seconds float64) {
+		fmt.Fprintf(&b, "time_to_k8s_phase_seconds{distro=%q,version=%q,platform=%q,iteration=\"%d\",phase=%q} %f\n",
+			e.Name, e.Version, platform, iteration, phase, seconds)
+	}
+
+	for phase, d := range e.Phases {
+		writeGauge(phase, d.Seconds())
+	}
+
+	fmt.Fprintf(&b, "time_to_k8s_total_seconds{distro=%q,version=%q,platform=%q,iteration=\"%d\"} %f\n",
+		e.Name, e.Version, platform, iteration, e.Total.Seconds())
+	fmt.Fprintf(&b, "time_to_k8s_cpu_seconds{distro=%q,version=%q,platform=%q,iteration=\"%d\"} %f\n",
+		e.Name, e.Version, platform, iteration, e.CPUTime.Seconds())
+
+	return b.String()
+}
+
+// metricsWriter appends rendered Prometheus text-format metrics to a file,
+// serializing concurrent writers from --parallel workers.
+type metricsWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newMetricsWriter(w io.Writer) *metricsWriter {
+	return &metricsWriter{w: w}
+}
+
+func (m *metricsWriter) write(body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := io.WriteString(m.w, body)
+	return err
+}
+
+// prometheusWriter is set up once in main when --prometheus-output is given;
+// nil means no local Prometheus-format output is written.
+var prometheusWriter *metricsWriter
+
+// pushMetrics pushes rendered Prometheus text format metrics to a Pushgateway,
+// grouped under the time_to_k8s job and the test case name as the instance.
+func pushMetrics(gatewayURL string, testCase string, body string) error {
+	url := fmt.Sprintf("%s/metrics/job/time_to_k8s/instance/%s", strings.TrimSuffix(gatewayURL, "/"), testCase)
+
+	resp, err := http.Post(url, "text/plain; version=0.0.4", strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("push to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("push to %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// nodeListJSON is the subset of `kubectl get nodes -o json` this tool reads
+// to evaluate a "node_ready_check" phase.
+type nodeListJSON struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Conditions []struct {
+				Type               string    `json:"type"`
+				Status             string    `json:"status"`
+				LastTransitionTime time.Time `json:"lastTransitionTime"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// podListJSON is the subset of `kubectl get pods -o json` this tool reads to
+// evaluate a "pod_spread_check" phase.
+type podListJSON struct {
+	Items []struct {
+		Spec struct {
+			NodeName string `json:"nodeName"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// countReadyNodes returns how many nodes in a `kubectl get nodes -o json`
+// listing report a Ready condition with status "True".
+func countReadyNodes(stdout []byte) int {
+	var list nodeListJSON
+	if err := json.Unmarshal(stdout, &list); err != nil {
+		return 0
+	}
+
+	ready := 0
+	for _, node := range list.Items {
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == "Ready" && cond.Status == "True" {
+				ready++
+				break
+			}
+		}
+	}
+	return ready
+}
+
+// nodeJoinTimes returns, for every Ready node in a `kubectl get nodes -o
+// json` listing, how long it took to become Ready since the given time.
+func nodeJoinTimes(stdout []byte, since time.Time) map[string]time.Duration {
+	var list nodeListJSON
+	if err := json.Unmarshal(stdout, &list); err != nil {
+		return nil
+	}
+
+	times := map[string]time.Duration{}
+	for _, node := range list.Items {
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == "Ready" && cond.Status == "True" && !cond.LastTransitionTime.IsZero() {
+				times[node.Metadata.Name] = cond.LastTransitionTime.Sub(since)
+				break
+			}
+		}
+	}
+	return times
+}
+
+// countScheduledNodes returns how many distinct nodes have a Running pod in
+// a `kubectl get pods -o json` listing, used to evaluate a "pod_spread_check"
+// phase (e.g. has a DaemonSet landed on every node yet).
+func countScheduledNodes(stdout []byte) int {
+	var list podListJSON
+	if err := json.Unmarshal(stdout, &list); err != nil {
+		return 0
+	}
+
+	nodes := map[string]bool{}
+	for _, pod := range list.Items {
+		if pod.Status.Phase == "Running" && pod.Spec.NodeName != "" {
+			nodes[pod.Spec.NodeName] = true
+		}
+	}
+	return len(nodes)
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// processHints lists process-name substrings that commonly belong to a
+// running Kubernetes distro, used to attribute RSS when the distro's own
+// invocation (binary) has already exited by the time its control plane is
+// up (e.g. minikube forks a background VM/container, kind's nodes run as
+// detached containers).
+var processHints = []string{
+	"kubelet", "containerd", "dockerd", "etcd",
+	"kube-apiserver", "kube-controller-manager", "kube-scheduler", "coredns",
+}
+
+// clockTicksPerSec is the kernel's USER_HZ, the unit of /proc/[pid]/stat
+// utime+stime (in ticks). 100 on every Linux platform this tool targets.
+// The sampler does not read per-process CPU ticks, and nothing in this
+// package reads this constant.
+const clockTicksPerSec = 100
+
+// sysSample is one full-system resource reading taken at ~1s intervals for
+// the lifetime of an iteration. Disk/net fields are deltas since the
+// previous sample; CPU fields are percentages of the ticks elapsed since the
+// previous sample. All of them are zero in the first sample.
+type sysSample struct {
+	Timestamp      time.Time          `json:"timestamp"`
+	CPUPercent     float64            `json:"cpu_percent"`          // host-wide, all cores
+	PerCoreCPU     map[string]float64 `json:"per_core_cpu_percent"` // keyed by /proc/stat core label, e.g. "cpu0"
+	DistroRSSBytes uint64             `json:"distro_rss_bytes"`     // summed RSS of the worker's cgroup members, or, without a cgroup, of processes matching the worker's profile and binary name or processHints
+	DiskReadBytes  uint64             `json:"disk_read_bytes"`
+	DiskWriteBytes uint64             `json:"disk_write_bytes"`
+	NetRecvBytes   uint64             `json:"net_recv_bytes"`
+	NetSentBytes   uint64             `json:"net_sent_bytes"`
+}
+
+// sysSummary rolls up a sampling run into the fields that get a CSV column,
+// alongside the raw samples persisted to a sidecar file.
+type sysSummary struct {
+	PeakRSSBytes   uint64
+	TotalDiskBytes uint64
+	TotalNetBytes  uint64
+	Samples        []sysSample
+}
+
+// sysSampler walks /proc at a fixed interval, recording host-wide CPU and disk
+// counters, the primary interface's network counters, plus the RSS of the
+// worker's actual process tree.
+// Unlike a single-pid sampler, this also catches control-plane components a
+// distro forks into the background (VMs, detached containers) that are no
+// longer children of the invoking process by the time it's up: cgroup, if
+// set, tracks that whole tree by membership rather than the PPID chain,
+// since membership (unlike PPID) survives the reparenting to init that
+// happens when the invoking process exits.
+type sysSampler struct {
+	nameHints []string
+	// profile, if set, scopes RSS matching to processes whose cmdline also
+	// contains this worker's cluster/profile name (see profileName). Only
+	// used as a fallback when cgroup is nil, e.g. on hosts without cgroup v2.
+	profile string
+	// cgroup, if set, is the authoritative source of which processes belong
+	// to this worker: RSS is summed over its membership instead of matching
+	// process names across the whole of /proc.
+	cgroup   *workerCgroup
+	interval time.Duration
+
+	mu      sync.Mutex
+	samples []sysSample
+}
+
+func newSysSampler(binaryName string, profile string, cgroup *workerCgroup, interval time.Duration) *sysSampler {
+	hints := append([]string{binaryName}, processHints...)
+	return &sysSampler{nameHints: hints, profile: profile, cgroup: cgroup, interval: interval}
+}
+
+// sumRSS returns the summed RSS of the worker's process tree: over cgroup
+// membership when available, falling back to profile-scoped process-name
+// matching otherwise.
+func (s *sysSampler) sumRSS() (uint64, error) {
+	if s.cgroup != nil {
+		return sumRSSInCgroup(s.cgroup)
+	}
+	return sumRSSByName(s.nameHints, s.profile)
+}
+
+// run samples until ctx is canceled. Errors reading /proc are logged and
+// skipped rather than treated as fatal: resource sampling is best-effort
+// and must never abort the benchmark itself.
+func (s *sysSampler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var prevCPUBusy, prevCPUTotal uint64
+	var prevPerCore map[string][2]uint64
+	var prevDiskRead, prevDiskWrite uint64
+	var prevNetRecv, prevNetSent uint64
+	first := true
+
+	iface := primaryInterface()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			busy, total, err := readCPUTicks()
+			if err != nil {
+				klog.Errorf("sysstat: cpu: %v", err)
+				continue
+			}
+			perCore, err := readPerCoreCPUTicks()
+			if err != nil {
+				klog.Errorf("sysstat: per-core cpu: %v", err)
+			}
+			diskRead, diskWrite, err := readDiskBytes()
+			if err != nil {
+				klog.Errorf("sysstat: disk: %v", err)
+			}
+			netRecv, netSent, err := readInterfaceBytes(iface)
+			if err != nil {
+				klog.Errorf("sysstat: net: %v", err)
+			}
+			rss, err := s.sumRSS()
+			if err != nil {
+				klog.Errorf("sysstat: rss: %v", err)
+			}
+
+			sample := sysSample{Timestamp: now, DistroRSSBytes: rss}
+			if !first {
+				if totalDelta := total - prevCPUTotal; totalDelta > 0 {
+					sample.CPUPercent = 100 * float64(busy-prevCPUBusy) / float64(totalDelta)
+				}
+				sample.PerCoreCPU = map[string]float64{}
+				for core, ticks := range perCore {
+					prev, ok := prevPerCore[core]
+					if !ok {
+						continue
+					}
+					if delta := ticks[1] - prev[1]; delta > 0 {
+						sample.PerCoreCPU[core] = 100 * float64(ticks[0]-prev[0]) / float64(delta)
+					}
+				}
+				sample.DiskReadBytes = diskRead - prevDiskRead
+				sample.DiskWriteBytes = diskWrite - prevDiskWrite
+				sample.NetRecvBytes = netRecv - prevNetRecv
+				sample.NetSentBytes = netSent - prevNetSent
+			}
+
+			prevCPUBusy, prevCPUTotal = busy, total
+			prevPerCore = perCore
+			prevDiskRead, prevDiskWrite = diskRead, diskWrite
+			prevNetRecv, prevNetSent = netRecv, netSent
+			first = false
+
+			s.mu.Lock()
+			s.samples = append(s.samples, sample)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// summary returns the peak RSS and cumulative disk/net IO seen so far,
+// alongside the raw samples for the sidecar file. Safe to call once
+// sampling has stopped.
+func (s *sysSampler) summary() sysSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := sysSummary{Samples: append([]sysSample{}, s.samples...)}
+	for _, sample := range s.samples {
+		if sample.DistroRSSBytes > sum.PeakRSSBytes {
+			sum.PeakRSSBytes = sample.DistroRSSBytes
+		}
+		sum.TotalDiskBytes += sample.DiskReadBytes + sample.DiskWriteBytes
+		sum.TotalNetBytes += sample.NetRecvBytes + sample.NetSentBytes
+	}
+	return sum
+}
+
+// readCPUTicks returns the host-wide busy and total tick counts from the
+// aggregate "cpu" line of /proc/stat.
+func readCPUTicks() (busy uint64, total uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[0] != "cpu" {
+			continue
+		}
+		var vals []uint64
+		for _, v := range fields[1:] {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+			vals = append(vals, n)
+			total += n
+		}
+		// vals: user, nice, system, idle, iowait, irq, softirq, steal, ...
+		idle := vals[3]
+		if len(vals) > 4 {
+			idle += vals[4]
+		}
+		busy = total - idle
+		return busy, total, nil
+	}
+	return 0, 0, scanner.Err()
+}
+
+// readPerCoreCPUTicks returns busy and total tick counts for every per-core
+// line of /proc/stat ("cpu0", "cpu1", ...), keyed by that label.
+func readPerCoreCPUTicks() (map[string][2]uint64, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cores := map[string][2]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[0] == "cpu" || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+
+		var total uint64
+		var vals []uint64
+		for _, v := range fields[1:] {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, n)
+			total += n
+		}
+
+		idle := vals[3]
+		if len(vals) > 4 {
+			idle += vals[4]
+		}
+		cores[fields[0]] = [2]uint64{total - idle, total}
+	}
+	return cores, scanner.Err()
+}
+
+// wholeBlockDevices returns the set of whole-disk device names known to the
+// kernel (e.g. "sda", "nvme0n1"), read from /sys/block. Partitions such as
+// "sda1" are listed only as subdirectories of their parent device, not as
+// top-level entries here, which is what lets readDiskBytes tell them apart
+// from /proc/diskstats's flat list.
+func wholeBlockDevices() (map[string]bool, error) {
+	entries, err := os.ReadDir("/sys/block")
+	if err != nil {
+		return nil, err
+	}
+
+	devices := map[string]bool{}
+	for _, entry := range entries {
+		devices[entry.Name()] = true
+	}
+	return devices, nil
+}
+
+// readDiskBytes sums read/write bytes across every whole block device listed
+// in /proc/diskstats, skipping partitions ("sda1", "nvme0n1p1", ...) since
+// their I/O is already counted in their parent device's totals. Sector
+// counts are always 512 bytes regardless of the device's actual sector size.
+func readDiskBytes() (read uint64, written uint64, err error) {
+	devices, err := wholeBlockDevices()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	f, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || !devices[fields[2]] {
+			continue
+		}
+		readSectors, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			continue
+		}
+		writeSectors, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			continue
+		}
+		read += readSectors * 512
+		written += writeSectors * 512
+	}
+	return read, written, scanner.Err()
+}
+
+// readInterfaceBytes returns the cumulative received and sent byte counters
+// for iface from /proc/net/dev.
+func readInterfaceBytes(iface string) (recv uint64, sent uint64, err error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, stats, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) != iface {
+			continue
+		}
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			continue
+		}
+		recv, err = strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		sent, err = strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		return recv, sent, nil
+	}
+	return 0, 0, scanner.Err()
+}
+
+// primaryInterface returns the interface that owns the default route, or
+// the first non-loopback interface in /proc/net/dev if none is found.
+func primaryInterface() string {
+	if f, err := os.Open("/proc/net/route"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[1] == "00000000" {
+				return fields[0]
+			}
+		}
+	}
+
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return "eth0"
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 2 && scanner.Scan(); i++ {
+		// skip the two header lines
+	}
+	for scanner.Scan() {
+		name, _, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if name != "lo" {
+			return name
+		}
+	}
+	return "eth0"
+}
+
+// sumRSSByName sums the resident set size of every process whose
+// /proc/[pid]/cmdline contains one of nameHints (case-insensitive) and,
+// when profile is non-empty, also contains profile — scoping the sum to a
+// single worker's cluster so concurrent --parallel workers running the same
+// distro don't sum each other's processes together.
+func sumRSSByName(nameHints []string, profile string) (uint64, error) {
+	procDirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+
+	profile = strings.ToLower(profile)
+
+	var total uint64
+	for _, entry := range procDirs {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		cmdline, err := os.ReadFile("/proc/" + entry.Name() + "/cmdline")
+		if err != nil {
+			continue
+		}
+		cmd := strings.ToLower(string(cmdline))
+
+		if profile != "" && !strings.Contains(cmd, profile) {
+			continue
+		}
+
+		matched := false
+		for _, hint := range nameHints {
+			if hint != "" && strings.Contains(cmd, strings.ToLower(hint)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		rss, err := readRSSBytes(pid)
+		if err != nil {
+			continue
+		}
+		total += rss
+	}
+	return total, nil
+}
+
+// readRSSBytes reads a process's resident set size from /proc/[pid]/status,
+// in bytes.
+func readRSSBytes(pid int) (uint64, error) {
+	f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "VmRSS:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb * 1024, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// cgroupRoot is the cgroup v2 unified hierarchy mountpoint this tool creates
+// scratch cgroups under to scope RSS sampling to a single worker's actual
+// process tree (see newWorkerCgroup).
+const cgroupRoot = "/sys/fs/cgroup"
+
+// workerCgroup is a cgroup v2 scratch group created for one iteration's
+// Setup process tree, so RSS can be summed only over processes that tree
+// actually spawned -- including daemons a distro (e.g. minikube) detaches
+// into the background and reparents to init, since cgroup membership
+// (unlike PPID) is fixed at fork time and survives reparenting.
+type workerCgroup struct {
+	path string
+}
+
+// newWorkerCgroup creates a fresh cgroup v2 scratch group named name under
+// cgroupRoot. ok is false, not an error, if cgroup v2 isn't available (e.g.
+// non-Linux, or a host still on the v1 hierarchy): RSS sampling is
+// best-effort and falls back to profileName-scoped process matching (see
+// sysSampler.sumRSS) rather than aborting the benchmark.
+func newWorkerCgroup(name string) (wc *workerCgroup, ok bool) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, false
+	}
+
+	path := filepath.Join(cgroupRoot, "time-to-k8s", name)
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		klog.Errorf("sysstat: create cgroup %s: %v", path, err)
+		return nil, false
+	}
+	return &workerCgroup{path: path}, true
+}
+
+// add moves pid into the cgroup. It must be called as soon as possible
+// after starting the process (see Run's onStart hook) and before it has a
+// chance to fork, since children only inherit their parent's cgroup
+// membership at fork time.
+func (wc *workerCgroup) add(pid int) error {
+	return os.WriteFile(filepath.Join(wc.path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0o644)
+}
+
+// pids returns every PID currently a member of the cgroup.
+func (wc *workerCgroup) pids() ([]int, error) {
+	data, err := os.ReadFile(filepath.Join(wc.path, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// close removes the scratch cgroup. Safe to call once every process in it
+// has exited (e.g. after Teardown has run); failures are logged and
+// otherwise ignored since this runs during best-effort iteration cleanup.
+func (wc *workerCgroup) close() {
+	if err := os.Remove(wc.path); err != nil {
+		klog.Errorf("sysstat: remove cgroup %s: %v", wc.path, err)
+	}
+}
+
+// sumRSSInCgroup sums the resident set size of every process currently a
+// member of wc.
+func sumRSSInCgroup(wc *workerCgroup) (uint64, error) {
+	pids, err := wc.pids()
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	for _, pid := range pids {
+		rss, err := readRSSBytes(pid)
+		if err != nil {
+			continue
+		}
+		total += rss
+	}
+	return total, nil
+}
+
+// profileFlags lists the command-line flags common distros use to name a
+// cluster/profile, e.g. "minikube start -p minikube-p2" or
+// "kind create cluster --name kind-p2".
+var profileFlags = []string{"-p", "--profile", "--name"}
+
+// profileName scans a worker's (already {{.Worker}}-substituted) setup
+// command for one of profileFlags and returns the cluster/profile name that
+// follows it, or "" if none is found (e.g. a single-node config with no
+// explicit profile flag).
+func profileName(setupArgs []string) string {
+	for i, arg := range setupArgs {
+		for _, flag := range profileFlags {
+			if arg == flag && i+1 < len(setupArgs) {
+				return setupArgs[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// writeSysstatSidecar persists a sysSampler's raw time-series to path as
+// newline-delimited JSON, one sysSample per line, so per-iteration resource
+// curves (not just the CSV summary columns) can be inspected or graphed.
+func writeSysstatSidecar(path string, samples []sysSample) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, s := range samples {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
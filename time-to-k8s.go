@@ -4,18 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/tstromberg/cstat/pkg/cstat"
 	"gopkg.in/yaml.v2"
 	"k8s.io/klog/v2"
 )
@@ -25,8 +30,26 @@ var (
 	configPath     = flag.String("config", "", "configuration file to load test cases from")
 	testTimeout    = flag.Duration("timeout", 6*time.Minute, "maximum time a test can take")
 	outputPath     = flag.String("output", "", "path to output generated CSV to")
+	parallelCount  = flag.Int("parallel", 1, "how many test cases to run concurrently, each in its own scratch dir and cluster/profile")
+
+	stepTimeout       = flag.Duration("step-timeout", 90*time.Second, "maximum time a single retried step may take, independent of --timeout")
+	backoffInitial    = flag.Duration("backoff-initial", 50*time.Millisecond, "initial delay between retries of a step")
+	backoffMultiplier = flag.Float64("backoff-multiplier", 1.5, "multiplier applied to the backoff delay after each failed attempt")
+	backoffMax        = flag.Duration("backoff-max", 5*time.Second, "maximum delay between retries of a step, before jitter is applied")
+	backoffJitter     = flag.Float64("backoff-jitter", 0.2, "jitter fraction (+/-) applied to each backoff delay")
+
+	jsonlPath            = flag.String("jsonl", "", "path to write newline-delimited JSON run records to (defaults alongside --output)")
+	pushgatewayURL       = flag.String("pushgateway", "", "optional Prometheus Pushgateway URL to push metrics to after each iteration")
+	prometheusOutputPath = flag.String("prometheus-output", "", "optional path to append Prometheus/OpenMetrics text-format metrics to after each iteration")
+
+	sysstatInterval = flag.Duration("sysstat-interval", time.Second, "how often to sample full-system CPU, RSS, disk and network usage during an iteration")
+	sysstatDir      = flag.String("sysstat-dir", "", "directory to write per-iteration sysstat time-series sidecar files to (defaults alongside --output)")
 )
 
+// csvMu guards the shared CSV writer. Rows are written from main's loop once
+// every worker of an iteration has finished, in test case name order.
+var csvMu sync.Mutex
+
 // ExperimentResult stores the result of a single experiment run
 type ExperimentResult struct {
 	Name          string
@@ -43,6 +66,71 @@ type ExperimentResult struct {
 	Error         string
 	Timestamp     time.Time
 	CPUTime       time.Duration
+	// PeakRSSBytes, DiskIOBytes and NetIOBytes summarize the sysSampler
+	// time-series recorded for this iteration (see sysstat.go); the raw
+	// samples are persisted separately to a sidecar file.
+	PeakRSSBytes uint64
+	DiskIOBytes  uint64
+	NetIOBytes   uint64
+	// NodesReady is how many nodes were Ready by the time a "node_ready_check"
+	// phase (see Phase.Type) succeeded, and NodeJoinTimes holds each ready
+	// node's join latency (time from iteration start to its Ready condition),
+	// keyed by node name. Both are zero-valued when no "node_ready_check"
+	// phase ran.
+	NodesReady    int
+	NodeJoinTimes map[string]time.Duration
+	// Phases holds the retry-accumulated duration of every phase that ran, keyed by
+	// phase name. It is populated regardless of whether a phase's Field maps to one
+	// of the named fields above, so custom phases remain visible even without a
+	// dedicated CSV column.
+	Phases map[string]time.Duration
+	// PhaseAttempts holds how many attempts each phase needed to succeed.
+	PhaseAttempts map[string]int
+	// RetryWait is the total wall-time spent sleeping between attempts, summed
+	// across every phase, so users can tell a slow distro from a flaky one.
+	RetryWait time.Duration
+}
+
+// recordPhase accumulates a finished phase's result into both the generic
+// Phases/PhaseAttempts maps and, when Field names one of ExperimentResult's
+// legacy duration fields, into that field too (so existing CSV columns keep working).
+func (e *ExperimentResult) recordPhase(p Phase, rr *RunResult) {
+	if e.Phases == nil {
+		e.Phases = map[string]time.Duration{}
+	}
+	e.Phases[p.Name] += rr.Duration
+
+	if e.PhaseAttempts == nil {
+		e.PhaseAttempts = map[string]int{}
+	}
+	e.PhaseAttempts[p.Name] += rr.Attempts
+	e.RetryWait += rr.RetryWait
+
+	switch p.Field {
+	case "Startup":
+		e.Startup += rr.Duration
+	case "APIAnswering":
+		e.APIAnswering += rr.Duration
+	case "KubernetesSvc":
+		e.KubernetesSvc += rr.Duration
+	case "DNSSvc":
+		e.DNSSvc += rr.Duration
+	case "AppRunning":
+		e.AppRunning += rr.Duration
+	case "DNSAnswering":
+		e.DNSAnswering += rr.Duration
+	}
+}
+
+// totalAttempts sums PhaseAttempts across every phase, giving users a single
+// retry-count column alongside RetryWait to judge whether a distro is slow
+// or just flaky.
+func (e *ExperimentResult) totalAttempts() int {
+	total := 0
+	for _, attempts := range e.PhaseAttempts {
+		total += attempts
+	}
+	return total
 }
 
 // RunResult stores the result of an cmd.Run call
@@ -52,12 +140,144 @@ type RunResult struct {
 	ExitCode int
 	Duration time.Duration
 	Args     []string
+	// Attempts and RetryWait are only populated by RetryRun: the number of times
+	// the command was executed, and the total time spent sleeping between attempts.
+	Attempts  int
+	RetryWait time.Duration
 }
 
-// TestCase is a testcase
+// TestCase is a testcase. Setup and Teardown may reference the {{.Worker}}
+// template token, which is substituted with a 1-indexed worker number so
+// that concurrent workers (see --parallel) can use distinct cluster/profile
+// names, e.g. "minikube start -p minikube-p{{.Worker}}".
+//
+// Phases is an ordered list of steps to run after Setup; if left empty, the
+// default netcat-based sequence (API server, kubernetes svc, dns svc, app
+// running, dns answering) is used instead.
 type TestCase struct {
-	Setup    string `yaml:"setup"`
-	Teardown string `yaml:"teardown"`
+	Setup    string  `yaml:"setup"`
+	Teardown string  `yaml:"teardown"`
+	Phases   []Phase `yaml:"phases"`
+	// Nodes is how many nodes a multi-node TestCase's "node_ready_check" and
+	// "pod_spread_check" phases (see Phase.Type) wait for. Unset or 1 means
+	// the existing single-node behavior.
+	Nodes int `yaml:"nodes"`
+}
+
+// nodeCount returns tc.Nodes, defaulting to 1 for TestCases that don't set it.
+func (tc TestCase) nodeCount() int {
+	if tc.Nodes <= 0 {
+		return 1
+	}
+	return tc.Nodes
+}
+
+// Phase is a single named step run (with retries) after cluster Setup. Command
+// may reference {{.Worker}} like Setup/Teardown. At most one of StdoutRegex or
+// JSONPath should be set; an unset predicate means "exit code 0".
+type Phase struct {
+	Name string `yaml:"name"`
+	// Command is the full command line to run, e.g. "kubectl get po -A".
+	Command string `yaml:"command"`
+	// StdoutRegex, if set, must match the command's stdout for the phase to succeed.
+	StdoutRegex string `yaml:"stdout_regex"`
+	// JSONPath, if set, is a dot-separated path (e.g. "status.phase") looked up in
+	// stdout parsed as JSON. The phase succeeds once the path resolves, or, if
+	// JSONEquals is also set, once the resolved value equals it.
+	JSONPath   string `yaml:"jsonpath"`
+	JSONEquals string `yaml:"jsonpath_equals"`
+	// Field names the ExperimentResult field this phase's retry-accumulated
+	// duration should add into (e.g. "APIAnswering"). Unrecognized or empty
+	// values still show up under ExperimentResult.Phases[Name].
+	Field string `yaml:"field"`
+	// Type selects specialized success criteria for multi-node benchmarking,
+	// overriding StdoutRegex/JSONPath. "" means a regular command phase.
+	// "node_ready_check" runs Command (expected to list nodes as JSON, e.g.
+	// "kubectl get nodes -o json") and succeeds once TestCase.Nodes nodes
+	// report a Ready condition; per-node join times go into
+	// ExperimentResult.NodeJoinTimes. "pod_spread_check" runs Command
+	// (expected to list a DaemonSet's pods as JSON) and succeeds once a
+	// Running pod has landed on TestCase.Nodes distinct nodes.
+	Type string `yaml:"type"`
+}
+
+// defaultPhases reproduces the tool's original hard-coded netcat workload as a
+// phase list, so configs without a "phases" section behave exactly as before.
+func defaultPhases(ctxArgs string) []Phase {
+	// Phases run inside the iteration's scratch dir, so resolve the manifest
+	// against the directory the tool was started from.
+	manifest, err := filepath.Abs("manifests/netcat-svc.yaml")
+	if err != nil {
+		manifest = "manifests/netcat-svc.yaml"
+	}
+	return []Phase{
+		{Name: "api-answering", Command: strings.TrimSpace("kubectl " + ctxArgs + " get po -A"), Field: "APIAnswering"},
+		{Name: "kubernetes-svc", Command: strings.TrimSpace("kubectl " + ctxArgs + " get svc kubernetes"), Field: "KubernetesSvc"},
+		{Name: "dns-svc", Command: strings.TrimSpace("kubectl " + ctxArgs + " get svc kube-dns -n kube-system"), Field: "DNSSvc"},
+		{Name: "netcat-apply", Command: strings.TrimSpace("kubectl " + ctxArgs + " apply -f " + manifest), Field: "AppRunning"},
+		{Name: "netcat-nc", Command: strings.TrimSpace("kubectl " + ctxArgs + " exec deployment/netcat -- nc -v localhost 8080"), Field: "AppRunning"},
+		{Name: "netcat-nslookup", Command: strings.TrimSpace("kubectl " + ctxArgs + " exec deployment/netcat -- nslookup netcat.default"), Field: "DNSAnswering"},
+	}
+}
+
+// phaseSucceeded evaluates a phase's success predicate against a command result.
+func phaseSucceeded(p Phase, tc TestCase, rr *RunResult, err error) bool {
+	switch p.Type {
+	case "node_ready_check":
+		return err == nil && countReadyNodes(rr.Stdout.Bytes()) >= tc.nodeCount()
+	case "pod_spread_check":
+		return err == nil && countScheduledNodes(rr.Stdout.Bytes()) >= tc.nodeCount()
+	case "":
+		// fall through to the generic predicates below
+	default:
+		klog.Errorf("phase %q: unrecognized type %q, treating as failed", p.Name, p.Type)
+		return false
+	}
+	if p.StdoutRegex != "" {
+		matched, rerr := regexp.MatchString(p.StdoutRegex, rr.Stdout.String())
+		return rerr == nil && matched
+	}
+	if p.JSONPath != "" {
+		val, ok := jsonPathLookup(rr.Stdout.Bytes(), p.JSONPath)
+		if !ok {
+			return false
+		}
+		if p.JSONEquals != "" {
+			return val == p.JSONEquals
+		}
+		return true
+	}
+	return err == nil
+}
+
+// jsonPathLookup walks a dot-separated path (e.g. "status.phase") through
+// stdout parsed as JSON, returning its value as a string.
+func jsonPathLookup(stdout []byte, path string) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return "", false
+	}
+
+	for _, key := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		v, ok = m[key]
+		if !ok {
+			return "", false
+		}
+	}
+
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	return fmt.Sprintf("%v", v), true
+}
+
+// substituteWorker replaces the {{.Worker}} template token with a 1-indexed worker number.
+func substituteWorker(s string, workerID int) string {
+	return strings.ReplaceAll(s, "{{.Worker}}", fmt.Sprintf("%d", workerID+1))
 }
 
 // diskConfig is a YAML config
@@ -65,8 +285,11 @@ type diskConfig struct {
 	TestCases map[string]TestCase
 }
 
-// Run is a helper to log command execution
-func Run(cmd *exec.Cmd) (*RunResult, error) {
+// Run is a helper to log command execution. onStart, if given, is called
+// with the process's PID right after it starts and before Run waits for it
+// to finish, e.g. to join a cgroup before the process has a chance to fork
+// children that would otherwise be missed (see workerCgroup in sysstat.go).
+func Run(cmd *exec.Cmd, onStart ...func(pid int)) (*RunResult, error) {
 	rr := &RunResult{Args: cmd.Args}
 
 	var outb, errb bytes.Buffer
@@ -75,7 +298,14 @@ func Run(cmd *exec.Cmd) (*RunResult, error) {
 
 	start := time.Now()
 	klog.V(1).Infof("Running: %s", cmd)
-	err := cmd.Run()
+	if err := cmd.Start(); err != nil {
+		rr.Duration = time.Since(start)
+		return rr, err
+	}
+	if len(onStart) > 0 {
+		onStart[0](cmd.Process.Pid)
+	}
+	err := cmd.Wait()
 	rr.Duration = time.Since(start)
 
 	if err != nil {
@@ -92,45 +322,141 @@ func Run(cmd *exec.Cmd) (*RunResult, error) {
 	return rr, err
 }
 
-// RetryRun retries until a command succeeds, returning the full duration
-func RetryRun(cmd *exec.Cmd) (*RunResult, error) {
+// isHardFailure reports whether a failed attempt should abort immediately
+// rather than be retried, e.g. the binary doesn't exist, isn't executable, or
+// was invoked with a flag it doesn't understand - no amount of waiting fixes
+// those. exec.Error covers a PATH lookup failure; os.PathError also covers a
+// bad absolute/relative path, a non-executable file, or "exec format error"
+// surfacing from the underlying fork/exec syscall.
+func isHardFailure(rr *RunResult, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var execErr *exec.Error
+	var pathErr *os.PathError
+	if errors.As(err, &execErr) || errors.As(err, &pathErr) {
+		return true
+	}
+
+	stderr := strings.ToLower(rr.Stderr.String())
+	for _, marker := range []string{"unknown flag", "unknown command", "unrecognized option", "flag needs an argument", "command not found"} {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoffDelay computes the exponential backoff delay (with jitter) before
+// the next retry attempt, modeled on cenkalti/backoff's ExponentialBackOff.
+func backoffDelay(attempt int) time.Duration {
+	d := float64(*backoffInitial) * math.Pow(*backoffMultiplier, float64(attempt))
+	if max := float64(*backoffMax); d > max {
+		d = max
+	}
+
+	jitter := d * *backoffJitter
+	d += (rand.Float64()*2 - 1) * jitter
+	if d < 0 {
+		d = 0
+	}
+	return time.Duration(d)
+}
+
+// RetryRun retries cmd with exponential backoff until it succeeds, the
+// classifier in isHardFailure decides it never will, or --step-timeout (a
+// deadline independent of the overall --timeout) elapses. It returns the full
+// accumulated execution duration, attempt count, and time spent waiting
+// between attempts. An optional success predicate can be passed to judge
+// success by something other than a zero exit code (e.g. stdout/JSONPath
+// matching for phases).
+func RetryRun(ctx context.Context, cmd *exec.Cmd, succeeded ...func(*RunResult, error) bool) (*RunResult, error) {
+	ok := func(rr *RunResult, err error) bool { return err == nil }
+	if len(succeeded) > 0 {
+		ok = succeeded[0]
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, *stepTimeout)
+	defer cancel()
+
+	klog.Infof("Running %s until it succeeds (step budget %s) ...", cmd, *stepTimeout)
+
 	var rr *RunResult
 	var err error
-	klog.Infof("Running %s until it succeeds ...", cmd)
-
 	duration := time.Duration(0)
+	retryWait := time.Duration(0)
 	attempts := 0
 
-	for attempts < 5000 {
+	for {
 		// exec.Cmd can only be executed once, so build a new one)
-		rr, err = Run(exec.Command(cmd.Path, cmd.Args[1:]...))
+		next := exec.CommandContext(stepCtx, cmd.Path, cmd.Args[1:]...)
+		next.Dir = cmd.Dir
+		rr, err = Run(next)
 		duration += rr.Duration
 		rr.Duration = duration
+		attempts++
+		rr.Attempts = attempts
+		rr.RetryWait = retryWait
 
-		if err == nil {
-			klog.V(1).Infof("%s succeeded after %d attempts (duration: %s)", cmd.Args, attempts, duration)
-			return rr, err
+		if ok(rr, err) {
+			klog.V(1).Infof("%s succeeded after %d attempts (duration: %s, retry wait: %s)", cmd.Args, attempts, duration, retryWait)
+			return rr, nil
 		}
 
-		attempts++
-		klog.V(1).Infof("%s failed: %v (%d attempts)", cmd, err, attempts)
-		// brief break to avoid DoS attack
-		time.Sleep(10 * time.Millisecond)
-	}
+		if isHardFailure(rr, err) {
+			return rr, fmt.Errorf("%s failed with a hard failure, not retrying: %w", rr, err)
+		}
 
-	return rr, err
+		if stepCtx.Err() != nil {
+			return rr, fmt.Errorf("%s did not succeed within step timeout %s (%d attempts): %w", cmd, *stepTimeout, attempts, stepCtx.Err())
+		}
+
+		delay := backoffDelay(attempts - 1)
+		klog.V(1).Infof("%s failed: %v (%d attempts, retrying in %s)", cmd, err, attempts, delay)
+
+		select {
+		case <-stepCtx.Done():
+			return rr, fmt.Errorf("%s did not succeed within step timeout %s (%d attempts): %w", cmd, *stepTimeout, attempts, stepCtx.Err())
+		case <-time.After(delay):
+			retryWait += delay
+		}
+	}
 }
 
 func ds(d time.Duration) string {
 	return fmt.Sprintf("%.3f", d.Seconds())
 }
 
-func runIteration(name string, setupCmd string, cleanupCmd string) (e ExperimentResult, err error) {
-	setup := strings.Split(setupCmd, " ")
-	cleanup := strings.Split(cleanupCmd, " ")
+// cmdIn builds a command scoped to an iteration's scratch directory, so that
+// concurrent workers never collide over files the setup/teardown commands create.
+func cmdIn(ctx context.Context, dir string, name string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, name, args...)
+	c.Dir = dir
+	return c
+}
+
+func runIteration(name string, tc TestCase, workerID int, iteration int) (e ExperimentResult, err error) {
+	setup := strings.Fields(substituteWorker(tc.Setup, workerID))
+	cleanup := strings.Fields(substituteWorker(tc.Teardown, workerID))
 	binary := setup[0]
 
-	cr := cstat.NewRunner(time.Second)
+	scratchDir, err := ioutil.TempDir("", fmt.Sprintf("time-to-k8s-w%d-", workerID+1))
+	if err != nil {
+		return e, fmt.Errorf("scratch dir: %w", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	// wc tracks the setup process's actual process tree via cgroup
+	// membership, which (unlike PPID) survives a distro detaching daemons
+	// into the background and having them reparented to init. profile is a
+	// process-name-matching fallback for hosts without cgroup v2.
+	wc, cgroupOK := newWorkerCgroup(fmt.Sprintf("%s-w%d-i%d", name, workerID+1, iteration))
+	if !cgroupOK {
+		klog.Warningf("sysstat: cgroup v2 unavailable, falling back to profile-scoped process-name matching for RSS sampling")
+	}
+	profile := profileName(setup)
+	sampler := newSysSampler(filepath.Base(binary), profile, wc, *sysstatInterval)
 	var wg sync.WaitGroup
 
 	// maximum runtime of a test
@@ -139,6 +465,37 @@ func runIteration(name string, setupCmd string, cleanupCmd string) (e Experiment
 	defer func() {
 		cancel()
 		wg.Wait()
+
+		// Runs after the sampler goroutine has fully stopped (wg.Wait above),
+		// so there's no race reading its samples or folding them into e: the
+		// prior cstat-based accounting read e.Total from the sampling
+		// goroutine itself, before the main goroutine had finished computing it.
+		sum := sampler.summary()
+		if len(sum.Samples) > 1 {
+			var avgCPUPercent float64
+			for _, s := range sum.Samples[1:] {
+				avgCPUPercent += s.CPUPercent
+			}
+			avgCPUPercent /= float64(len(sum.Samples) - 1)
+			e.CPUTime = time.Duration(avgCPUPercent / 100 * float64(e.Total))
+		}
+		e.PeakRSSBytes = sum.PeakRSSBytes
+		e.DiskIOBytes = sum.TotalDiskBytes
+		e.NetIOBytes = sum.TotalNetBytes
+
+		if *sysstatDir != "" {
+			path := filepath.Join(*sysstatDir, fmt.Sprintf("%s-w%d-i%d.sysstat.jsonl", name, workerID+1, iteration))
+			if err := writeSysstatSidecar(path, sum.Samples); err != nil {
+				klog.Errorf("sysstat sidecar: %v", err)
+			}
+		}
+
+		// On success this runs after teardown (see below), so every process
+		// the cgroup ever gained should have exited by now. On an early error
+		// return teardown has not run, and a failed removal is only logged.
+		if wc != nil {
+			wc.close()
+		}
 	}()
 
 	klog.Infof("starting %q iteration. initialization args: %v, cleanup args: %v", name, setup, cleanup)
@@ -148,93 +505,83 @@ func runIteration(name string, setupCmd string, cleanupCmd string) (e Experiment
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		e.CPUTime = time.Duration(cr.Run(ctx).Busy * float64(e.Total.Nanoseconds()))
+		sampler.run(ctx)
 	}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for range cr.C() {
-		}
-	}()
-
-	rr, err := Run(exec.CommandContext(ctx, binary, "version"))
+	rr, err := Run(cmdIn(ctx, scratchDir, binary, "version"))
+	recordRun(iteration, name, "version", rr, nil)
 	if err != nil {
 		e.ExitCode = rr.ExitCode
 		return e, fmt.Errorf("%s failed: %w", rr, err)
 	}
 	e.Version = strings.Split(rr.Stdout.String(), "\n")[0]
 
-	rr, err = Run(exec.CommandContext(ctx, binary, setup[1:]...))
+	rr, err = Run(cmdIn(ctx, scratchDir, binary, setup[1:]...), func(pid int) {
+		if wc == nil {
+			return
+		}
+		if joinErr := wc.add(pid); joinErr != nil {
+			klog.Errorf("sysstat: join cgroup: %v", joinErr)
+		}
+	})
+	recordRun(iteration, name, "setup", rr, nil)
 	if err != nil {
 		e.ExitCode = rr.ExitCode
 		return e, fmt.Errorf("%s failed: %w", rr, err)
 	}
 	e.Startup = rr.Duration
 
-	extraArgs := []string{}
+	// give each worker its own cluster/profile name so parallel runs never collide.
+	profileSuffix := ""
+	if *parallelCount > 1 {
+		profileSuffix = fmt.Sprintf("-p%d", workerID+1)
+	}
 
+	ctxArgs := ""
 	if strings.Contains(binary, "kind") {
-		extraArgs = []string{"--context", "kind-kind"}
+		ctxArgs = "--context kind-kind" + profileSuffix
 	}
 	if strings.Contains(binary, "minikube") {
-		extraArgs = []string{"--context", "minikube"}
+		ctxArgs = "--context minikube" + profileSuffix
 	}
 	if strings.Contains(binary, "k3d") {
-		extraArgs = []string{"--context", "k3d-k3s-default"}
-	}
-
-	args := append(extraArgs, "get", "po", "-A")
-	rr, err = RetryRun(exec.CommandContext(ctx, "kubectl", args...))
-	if err != nil {
-		e.ExitCode = rr.ExitCode
-		return e, fmt.Errorf("%s failed: %w", rr, err)
-	}
-	e.APIAnswering = rr.Duration
-
-	args = append(extraArgs, "get", "svc", "kubernetes")
-	rr, err = RetryRun(exec.CommandContext(ctx, "kubectl", args...))
-	if err != nil {
-		e.ExitCode = rr.ExitCode
-		return e, fmt.Errorf("%s failed: %w", rr, err)
+		ctxArgs = "--context k3d-k3s-default" + profileSuffix
 	}
-	e.KubernetesSvc = rr.Duration
 
-	args = append(extraArgs, "get", "svc", "kube-dns", "-n", "kube-system")
-	rr, err = RetryRun(exec.CommandContext(ctx, "kubectl", args...))
-	if err != nil {
-		e.ExitCode = rr.ExitCode
-		return e, fmt.Errorf("%s failed: %w", rr, err)
+	phases := tc.Phases
+	if len(phases) == 0 {
+		phases = defaultPhases(ctxArgs)
 	}
-	e.DNSSvc = rr.Duration
 
-	args = append(extraArgs, "apply", "-f", "manifests/netcat-svc.yaml")
-	rr, err = RetryRun(exec.CommandContext(ctx, "kubectl", args...))
-	if err != nil {
-		e.ExitCode = rr.ExitCode
-		return e, fmt.Errorf("%s failed: %w", rr, err)
-	}
-	e.AppRunning = rr.Duration
-
-	args = append(extraArgs, "exec", "deployment/netcat", "--", "nc", "-v", "localhost", "8080")
-	rr, err = RetryRun(exec.CommandContext(ctx, "kubectl", args...))
-	if err != nil {
-		e.ExitCode = rr.ExitCode
-		return e, fmt.Errorf("%s failed: %w", rr, err)
-	}
-	e.AppRunning += rr.Duration
+	for _, p := range phases {
+		phaseArgs := strings.Fields(substituteWorker(p.Command, workerID))
+		rr, err = RetryRun(ctx, cmdIn(ctx, scratchDir, phaseArgs[0], phaseArgs[1:]...), func(rr *RunResult, err error) bool {
+			return phaseSucceeded(p, tc, rr, err)
+		})
+
+		var joinTimes map[string]time.Duration
+		if p.Type == "node_ready_check" && rr != nil {
+			e.NodesReady = countReadyNodes(rr.Stdout.Bytes())
+			joinTimes = nodeJoinTimes(rr.Stdout.Bytes(), e.Timestamp)
+			e.NodeJoinTimes = joinTimes
+		}
+		recordRun(iteration, name, p.Name, rr, joinTimes)
 
-	args = append(extraArgs, "exec", "deployment/netcat", "--", "nslookup", "netcat.default")
-	rr, err = RetryRun(exec.CommandContext(ctx, "kubectl", args...))
-	if err != nil {
-		e.ExitCode = rr.ExitCode
-		return e, fmt.Errorf("%s failed: %w", rr, err)
+		if err != nil {
+			e.ExitCode = rr.ExitCode
+			return e, fmt.Errorf("phase %q (%s) failed: %w", p.Name, rr, err)
+		}
+		e.recordPhase(p, rr)
 	}
-	e.DNSAnswering = rr.Duration
 
 	e.Total = e.Startup + e.APIAnswering + e.KubernetesSvc + e.DNSSvc + e.AppRunning + e.DNSAnswering
 
-	rr, err = RetryRun(exec.Command(cleanup[0], cleanup[1:]...))
+	// teardown runs against its own background context so that it always has a
+	// chance to clean up, even if the iteration's overall timeout has elapsed.
+	teardown := exec.Command(cleanup[0], cleanup[1:]...)
+	teardown.Dir = scratchDir
+	rr, err = RetryRun(context.Background(), teardown)
+	recordRun(iteration, name, "teardown", rr, nil)
 	if err != nil {
 		e.ExitCode = rr.ExitCode
 		return e, fmt.Errorf("%s failed: %w", rr, err)
@@ -250,6 +597,9 @@ func main() {
 	if *configPath == "" {
 		klog.Exitf("--config is a required flag. See ./local-kubernetes.yaml, for example")
 	}
+	if *parallelCount < 1 {
+		klog.Exitf("--parallel must be at least 1, got %d", *parallelCount)
+	}
 	f, err := ioutil.ReadFile(*configPath)
 	if err != nil {
 		klog.Exitf("unable to read config: %v", err)
@@ -273,17 +623,50 @@ func main() {
 
 	c := csv.NewWriter(outputFile)
 
-	c.Write([]string{"name", "args", "platform", "iteration", "time", "version", "exitcode", "error", "command exec (seconds)", "apiserver answering (seconds)", "kubernetes svc (seconds)", "dns svc (seconds)", "app running (seconds)", "dns answering (seconds)", "cpu time (seconds)", "total duration (seconds)"})
+	c.Write([]string{"name", "args", "platform", "iteration", "time", "version", "exitcode", "error", "command exec (seconds)", "apiserver answering (seconds)", "kubernetes svc (seconds)", "dns svc (seconds)", "app running (seconds)", "dns answering (seconds)", "cpu time (seconds)", "total duration (seconds)", "peak rss (bytes)", "total disk io (bytes)", "total net io (bytes)", "nodes ready", "retry attempts", "retry wait (seconds)"})
 	klog.Infof("Writing output to %s", outputFile.Name())
 	c.Flush()
 
-	// quick cleanup loop
+	jsonlName := *jsonlPath
+	if jsonlName == "" {
+		jsonlName = strings.TrimSuffix(outputFile.Name(), filepath.Ext(outputFile.Name())) + ".jsonl"
+	}
+	jsonlFile, err := os.Create(jsonlName)
+	if err != nil {
+		klog.Exitf("jsonl file: %v", err)
+	}
+	jsonlRecorder = newRunRecorder(jsonlFile)
+	klog.Infof("Writing per-command run records to %s", jsonlFile.Name())
+
+	if *sysstatDir == "" {
+		*sysstatDir = filepath.Dir(outputFile.Name())
+	}
+	klog.Infof("Writing per-iteration sysstat sidecar files to %s", *sysstatDir)
+
+	if *prometheusOutputPath != "" {
+		prometheusFile, err := os.Create(*prometheusOutputPath)
+		if err != nil {
+			klog.Exitf("prometheus output file: %v", err)
+		}
+		prometheusWriter = newMetricsWriter(prometheusFile)
+		klog.Infof("Writing Prometheus-format metrics to %s", prometheusFile.Name())
+	}
+
+	// quick cleanup loop - tear down any clusters left behind by a prior unclean run, for every worker slot
 	for name, tc := range dc.TestCases {
-		cleanup := strings.Split(tc.Teardown, " ")
-		klog.Infof("cleaning up %q with arguments: %v", name, cleanup)
-		Run(exec.Command(cleanup[0], cleanup[1:]...))
+		for w := 0; w < *parallelCount; w++ {
+			cleanup := strings.Fields(substituteWorker(tc.Teardown, w))
+			klog.Infof("cleaning up %q (worker %d) with arguments: %v", name, w+1, cleanup)
+			Run(exec.Command(cleanup[0], cleanup[1:]...))
+		}
 	}
 
+	names := make([]string, 0, len(dc.TestCases))
+	for name := range dc.TestCases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	for i := 0; i <= *iterationCount; i++ {
 		if i == 0 {
 			klog.Infof("Starting dry-run iteration - will not record results")
@@ -291,8 +674,31 @@ func main() {
 			klog.Infof("STARTING ITERATION COUNT %d of %d", i, *iterationCount)
 		}
 
-		for name, tc := range dc.TestCases {
-			e, err := runIteration(name, tc.Setup, tc.Teardown)
+		// results is indexed identically to names, so output ordering stays deterministic
+		// regardless of which worker finishes first.
+		results := make([]ExperimentResult, len(names))
+		errs := make([]error, len(names))
+
+		workers := make(chan int, *parallelCount)
+		for w := 0; w < *parallelCount; w++ {
+			workers <- w
+		}
+
+		var wg sync.WaitGroup
+		for idx, name := range names {
+			idx, name, tc := idx, name, dc.TestCases[name]
+			workerID := <-workers
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { workers <- workerID }()
+				results[idx], errs[idx] = runIteration(name, tc, workerID, i)
+			}()
+		}
+		wg.Wait()
+
+		for idx, name := range names {
+			e, err := results[idx], errs[idx]
 			if err != nil {
 				e.Error = err.Error()
 				if i == 0 {
@@ -305,6 +711,7 @@ func main() {
 				continue
 			}
 			klog.Infof("Updating %s ...", outputFile.Name())
+			csvMu.Lock()
 			fields := []string{
 				name,
 				strings.Join(e.Args, " "),
@@ -322,9 +729,31 @@ func main() {
 				ds(e.DNSAnswering),
 				ds(e.CPUTime),
 				ds(e.Total),
+				fmt.Sprintf("%d", e.PeakRSSBytes),
+				fmt.Sprintf("%d", e.DiskIOBytes),
+				fmt.Sprintf("%d", e.NetIOBytes),
+				fmt.Sprintf("%d", e.NodesReady),
+				fmt.Sprintf("%d", e.totalAttempts()),
+				ds(e.RetryWait),
 			}
 			c.Write(fields)
 			c.Flush()
+			csvMu.Unlock()
+
+			if *pushgatewayURL != "" || prometheusWriter != nil {
+				metrics := prometheusMetrics(e, runtime.GOOS, i)
+
+				if *pushgatewayURL != "" {
+					if perr := pushMetrics(*pushgatewayURL, name, metrics); perr != nil {
+						klog.Errorf("pushgateway: %v", perr)
+					}
+				}
+				if prometheusWriter != nil {
+					if perr := prometheusWriter.write(metrics); perr != nil {
+						klog.Errorf("prometheus output: %v", perr)
+					}
+				}
+			}
 		}
 	}
 